@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+/* Asks for "line" or "line:col" and jumps there, reading the file as far as
+   needed. The line ends up in the middle of the screen. */
+func handleGoto() {
+	answer, ok := promptLine("Go to line[:col]: ")
+	if !ok {
+		showEditorData()
+		return
+	}
+	var (
+		fields []string = strings.SplitN(strings.TrimSpace(answer), ":", 2)
+		col    int      = 1
+	)
+	index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+	if err == nil && len(fields) == 2 {
+		col, err = strconv.Atoi(strings.TrimSpace(fields[1]))
+	}
+	if err != nil || index < 1 || col < 1 {
+		setStatusMessage("-winter: Invalid line %v", answer)
+		showEditorData()
+		return
+	}
+
+	var half int = sb.VisibleRows() / 2
+	// the lines under it have to be there to fill the screen
+	sb.LoadUpTo(index + half)
+	if index > sb.Size() {
+		index = sb.Size()
+	}
+	line := sb.GetLine(index)
+	if line == nil {
+		return
+	}
+	if col > line.Length+1 {
+		col = line.Length + 1
+	}
+
+	var first int = index - half
+	if last := sb.Size() - sb.VisibleRows() + 1; first > last {
+		first = last
+	}
+	if first < 1 {
+		first = 1
+	}
+	// a jump stretches a selection started with the mark, like moving does
+	if !myState.markSet {
+		clearSelection()
+	}
+	sb.IndexOfFirstVisibleLine = first
+	sb.FirstVisibleRow = 0
+	gotoLine(index, sb.SnapColumn(line, col))
+	showEditorData()
+}
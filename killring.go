@@ -0,0 +1,133 @@
+package main
+
+import (
+	"easyterm"
+	"screenbuf"
+	"strings"
+)
+
+/* How many cut or copied texts are kept around for yank-pop */
+const KILL_RING_SIZE int = 32
+
+/* Copies also go to the system clipboard through OSC 52. Turn it off for
+   terminals that draw the sequence instead of taking it. */
+var systemClipboard bool = true
+
+var (
+	killRing  []string          // oldest first
+	yankIndex int               // entry the last paste came from
+	yanked    *screenbuf.Region // what the last paste put in, nil after any other key
+)
+
+/* Forgets the last paste when another key comes, yank-pop only follows a
+   paste or another yank-pop */
+func yankFor(key easyterm.Key) {
+	var (
+		paste bool = key.Code == easyterm.KeyCtrl && key.Mod == 0 && byte(key.Rune) == keyPaste
+		pop   bool = key.Code == easyterm.KeyRune && key.Mod == easyterm.ModAlt && key.Rune == keyYankPop
+	)
+	if !paste && !pop {
+		yanked = nil
+	}
+}
+
+func pushKill(text string) {
+	killRing = append(killRing, text)
+	if len(killRing) > KILL_RING_SIZE {
+		killRing = killRing[1:]
+	}
+	if systemClipboard {
+		myState.term.SetClipboard(text)
+	}
+}
+
+/* What cut and copy work on, the selection or else the whole current line.
+   The line comes with its newline so pasting it gives back a whole line,
+   the last one has none to give. */
+func killRegion() (screenbuf.Region, string) {
+	if r, ok := selectedRegion(); ok {
+		return r, regionText(r)
+	}
+	var (
+		line *BufferNode      = myState.currentLine
+		r    screenbuf.Region = screenbuf.Region{
+			Start: screenbuf.EditPos{Line: line.Index(), Col: 1},
+			End:   screenbuf.EditPos{Line: line.Index(), Col: line.Length + 1},
+		}
+		text string = line.Line
+	)
+	if next := sb.NextLine(line); next != nil {
+		r.End = screenbuf.EditPos{Line: next.Index(), Col: 1}
+		text += "\n"
+	}
+	return r, text
+}
+
+/* Lines text spans, a newline at the end doesn't start another one */
+func lineCount(text string) int {
+	return strings.Count(strings.TrimSuffix(text, "\n"), "\n") + 1
+}
+
+func cutText() {
+	r, text := killRegion()
+	if text == "" {
+		return
+	}
+	pushKill(text)
+	replaceRegion(r, "")
+	setStatusMessage("Cut %d line(s)", lineCount(text))
+	showEditorData()
+}
+
+func copyText() {
+	_, text := killRegion()
+	if text == "" {
+		return
+	}
+	pushKill(text)
+	clearSelection()
+	setStatusMessage("Copied %d line(s)", lineCount(text))
+	showEditorData()
+}
+
+/* Puts the newest text of the kill ring at the cursor, over the selection if
+   there is one */
+func pasteText() {
+	if len(killRing) == 0 {
+		setStatusMessage("Nothing to paste")
+		showEditorData()
+		return
+	}
+	r, ok := selectedRegion()
+	if !ok {
+		r = screenbuf.Region{Start: cursorEditPos(), End: cursorEditPos()}
+	}
+	yankIndex = len(killRing) - 1
+	inserted := replaceRegion(r, killRing[yankIndex])
+	yanked = &inserted
+}
+
+/* Puts text pasted into the terminal at the cursor in one go, over the
+   selection if there is one. It doesn't touch the kill ring. */
+func insertPaste(text string) {
+	// terminals send a newline as a carriage return
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	r, ok := selectedRegion()
+	if !ok {
+		r = screenbuf.Region{Start: cursorEditPos(), End: cursorEditPos()}
+	}
+	replaceRegion(r, text)
+}
+
+/* Swaps what the last paste put in for the entry before it in the ring */
+func yankPop() {
+	if yanked == nil {
+		setStatusMessage("Yank-pop only works right after a paste")
+		showEditorData()
+		return
+	}
+	yankIndex = (yankIndex + len(killRing) - 1) % len(killRing)
+	inserted := replaceRegion(*yanked, killRing[yankIndex])
+	yanked = &inserted
+}
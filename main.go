@@ -1,19 +1,17 @@
 package main
 
 import (
-	"bufio"
 	"easyterm"
 	"fmt"
-	"math"
 	"os"
 	"path/filepath"
 	"screenbuf"
 	"strings"
+	"unicode"
 )
 
-/* Alias ReadWriter */
+/* Aliases */
 type File = os.File
-type ReadWriter = bufio.ReadWriter
 type ScreenBuffer = screenbuf.ScreenBuffer
 type BufferNode = screenbuf.BufferNode
 
@@ -34,6 +32,10 @@ type WinterState struct {
 	currentLine *BufferNode
 	fileName string
 	filePath string
+	term        easyterm.Terminal
+	keys        *easyterm.KeyReader
+	anchor      *screenbuf.EditPos // where the selection started, nil without one
+	markSet     bool               // the selection came from the mark key
 }
 
 type WinterError struct {
@@ -57,196 +59,318 @@ func (e *WinterError) IsNewFile() bool {
 /* Global State */
 var myState WinterState
 
-/* stdin/stdout RW */
-var termRW *ReadWriter
-
 /* the ScreenBuffer*/
 var sb *ScreenBuffer
 
-func clearBuffer(buff []byte) {
-	buff[0] = 0
-	buff[1] = 0
-	buff[2] = 0
-	buff[3] = 0
-}
+/* Key bindings, change these to remap the commands */
+var (
+	keyUndo    byte = 31 // Ctrl-_ or Ctrl-/ on most terminals
+	keyRedo    byte = 25 // Ctrl-Y
+	keySuspend byte = 26 // Ctrl-Z
+	keyFind    byte = 6  // Ctrl-F
+	keyReplace byte = 18 // Ctrl-R
+	keyDebug   byte = 4  // Ctrl-D
+	keyWrap    byte = 20 // Ctrl-T
+	keyQuit    byte = 17 // Ctrl-Q
+	keyMark    byte = 0  // Ctrl-Space or Ctrl-@
+	keyGoto    byte = 7  // Ctrl-G
+
+	keyDeleteWord     byte = 23  // Ctrl-W, Alt-Backspace does the same
+	keyDeleteNextWord rune = 'd' // with Alt
+
+	keyCut     byte = 24  // Ctrl-X
+	keyCopy    byte = 3   // Ctrl-C
+	keyPaste   byte = 22  // Ctrl-V
+	keyYankPop rune = 'y' // with Alt, right after a paste
+)
 
-func updateCursorPosX(num int) {
-	if (myState.cursorPos.x + num) > 0 {
-		myState.cursorPos.x += num
+func moveCursorX(num int) {
+	var (
+		line   string = myState.currentLine.Line
+		offset int    = sb.LineOffset(line, myState.cursorPos.x)
+	)
+	// one character at a time, a tab or a wide character is a single step
+	for ; num > 0 && offset < len(line); num-- {
+		offset = screenbuf.NextOffset(line, offset)
+	}
+	for ; num < 0 && offset > 0; num++ {
+		offset = screenbuf.PrevOffset(line, offset)
 	}
+	myState.cursorPos.x = sb.RealColumn(line, offset)
+	showEditorData()
 }
 
-func updateCursorPosY(num int) {
-	if (myState.cursorPos.y + num) > 0 {
-		myState.cursorPos.y += num
+func moveCursorY(num int) {
+	var (
+		line *BufferNode = myState.currentLine
+		col  int         = myState.cursorPos.x
+		next *BufferNode
+	)
+	if sb.Wrap {
+		// move between the rows of a wrapped line first
+		var row int = sb.RowOf(line, col)
+		if (num > 0 && row < sb.LineRows(line)-1) || (num < 0 && row > 0) {
+			col = sb.RowColumn(line, row+num, col-sb.RowStart(line, row))
+			myState.cursorPos.x = sb.SnapColumn(line, col)
+			showEditorData()
+			return
+		}
 	}
-}
 
-func setCursorPos(y, x int) {
-	myState.cursorPos.x = x
-	myState.cursorPos.y = y
-}
+	if num > 0 {
+		// loads the next line from the file if there is one
+		next = sb.NextLine(line)
+	} else {
+		next = sb.PrevLine(line)
+	}
+	if next == nil {
+		return
+	}
 
-func moveCursorX(num int, fn func(int)) {
-	line_length := myState.currentLine.Length
-	// Handling tab movement
-	if (myState.cursorPos.x - 1) < line_length {
+	if sb.Wrap {
+		// keep the same column on screen, on the row next to the current one
+		var row int = 0
 		if num < 0 {
-			if sb.IsATabStop(myState.cursorPos.x-1) {
-				if strings.ContainsRune(myState.currentLine.RealLine[sb.PrevTabStop(myState.cursorPos.x-1):myState.cursorPos.x-1], 9){
-					for i:= myState.cursorPos.x-2; i >= 0; i-- {
-						if myState.currentLine.RealLine[i] == '\t' {
-							num *= ((myState.cursorPos.x - i) - 1) // to land on the char before the stop
-							break
-						}
-					}
-				}
-			}
-		}
-		if myState.currentLine.RealLine[myState.cursorPos.x-1] == '\t' {
-			if num > 0 {
-				var nextTab int = sb.NextTabStop(myState.cursorPos.x-1)
-				num *= (nextTab - myState.cursorPos.x) + 1 // Tab +1 to land on next editable char
-			}
+			row = sb.LineRows(next) - 1
 		}
+		col = sb.RowColumn(next, row, col-sb.RowStart(line, sb.RowOf(line, col)))
 	}
-	// Current pos + the next column < the length of the line
-	if ((myState.cursorPos.x - 1) + num) <= line_length {
-		fn(int(math.Abs(float64(num)))) // Calls the easyterm func with a positive number
-		updateCursorPosX(num)
+	if col > next.Length+1 {
+		col = next.Length + 1
 	}
+	myState.currentLine = next
+	myState.cursorPos.x = sb.SnapColumn(next, col)
 	showEditorData()
 }
 
-func moveCursorY(num int, fn func(int)) {
-	//buffer_length := sbGetBufferLength()
+/* Home goes to where the indentation ends first, pressed again it goes to
+   the first column */
+func moveCursorHome() {
 	var (
-		currentLine      *BufferNode
-		nextLine         *BufferNode
-		currentLineIndex int
+		line   string = myState.currentLine.Line
+		indent int    = sb.RealColumn(line, len(line)-len(strings.TrimLeft(line, " \t")))
 	)
-	currentLineIndex = myState.cursorPos.y + num
-	//easyterm.CursorPos(29, 80)
-	//fmt.Print("Y-CurrentLineIndex: ")
-	//fmt.Print(currentLineIndex)
-	if currentLineIndex == 0 {
-		// trying to go up in the file, load lines from above if any
-		sb.LoadLine(UP, myState.currentLine.Index)
-
-		currentLine = myState.currentLine
-		nextLine = sb.GetLine(currentLine.Index + num) // Get the previous node
-
-		if nextLine == nil {
-			return
-		}
+	if myState.cursorPos.x == indent {
+		myState.cursorPos.x = 1
+	} else {
+		myState.cursorPos.x = indent
+	}
+	showEditorData()
+}
 
-		if nextLine.Length < currentLine.Length {
-			easyterm.CursorPos(myState.cursorPos.y, nextLine.Length)
-			setCursorPos(myState.cursorPos.y, nextLine.Length+1)
-		}
+func moveCursorEnd() {
+	myState.cursorPos.x = myState.currentLine.Length + 1
+	showEditorData()
+}
 
-		myState.currentLine = nextLine
+/* Scrolls a screenful of lines up or down, the cursor moves along with the
+   text so it stays on the same row */
+func movePage(pages int) {
+	var (
+		page  int = sb.VisibleRows() * pages
+		index int = myState.currentLine.Index() + page
+		first int = sb.IndexOfFirstVisibleLine + page
+	)
+	if pages > 0 {
+		// the lines on the next screen may not have been read yet
+		sb.LoadUpTo(first + sb.VisibleRows())
+	}
+	if last := sb.Size() - sb.VisibleRows() + 1; first > last {
+		first = last
+	}
+	if first < 1 {
+		first = 1
+	}
+	if index > sb.Size() {
+		index = sb.Size()
+	}
+	if index < 1 {
+		index = 1
+	}
+	line := sb.GetLine(index)
+	if line == nil {
+		return
+	}
+	var col int = myState.cursorPos.x
+	if col > line.Length+1 {
+		col = line.Length + 1
+	}
+	sb.IndexOfFirstVisibleLine = first
+	sb.FirstVisibleRow = 0
+	gotoLine(index, sb.SnapColumn(line, col))
+	showEditorData()
+}
 
-	} else if currentLineIndex > 0 && currentLineIndex < sb.DefaultHeight {
-		currentLine = myState.currentLine
-		//updateCursorPosY(num)
-		nextLine = sb.GetLine(currentLine.Index + num)
+/* Ctrl-Home and Ctrl-End, the end needs the whole file read */
+func moveToTop() {
+	gotoLine(1, 1)
+	showEditorData()
+}
 
-		if nextLine == nil {
-			return
-		}
+func moveToBottom() {
+	sb.LoadAll()
+	if last := sb.GetLine(sb.Size()); last != nil {
+		gotoLine(last.Index(), last.Length+1)
+	}
+	showEditorData()
+}
 
-		fn(int(math.Abs(float64(num)))) // Calls the easyterm func with a positive number
-		updateCursorPosY(num)
+/* Delete removes the character under the cursor, at the end of a line it
+   joins the next line to this one */
+func deleteLetter() {
+	var (
+		line   string = myState.currentLine.Line
+		offset int    = sb.LineOffset(line, myState.cursorPos.x)
+		edit   *screenbuf.Edit
+	)
+	if offset < len(line) {
+		edit = beginEdit(screenbuf.EditDelete, myState.currentLine.Index(), 1)
+		// a tab or a character with its combining marks goes in one go
+		line = line[:offset] + line[screenbuf.NextOffset(line, offset):]
+		sb.SetLine(myState.currentLine, line)
+		sb.RedrawLine(myState.currentLine)
+	} else if next := sb.NextLine(myState.currentLine); next != nil {
+		edit = beginEdit(screenbuf.EditJoin, myState.currentLine.Index(), 2)
+		sb.SetLine(myState.currentLine, line+next.Line)
+		sb.DeleteLine(next)
+		sb.ReprintBuffer()
+	} else {
+		return
+	}
+	commitEdit(edit, 1)
+	showEditorData()
+}
 
-		if nextLine.Length < currentLine.Length {
-			//easyterm.CursorPos(0,1)
-			easyterm.CursorPos(myState.cursorPos.y, nextLine.Length)
-			setCursorPos(myState.cursorPos.y, nextLine.Length+1)
-		}
+/* Lays the screen out again for the new terminal size, scrolling the window
+   if needed to keep the cursor on the current line. Signals wake the main
+   loop up the same way, so they get handled here first. */
+func handleResize() {
+	handleCaughtSignals()
+	sb.Resize()
+	gotoLine(myState.currentLine.Index(), myState.cursorPos.x)
+	showEditorData()
+}
 
-		myState.currentLine = nextLine
+/* Puts the cursor on line index at column col, scrolling the view if needed */
+func gotoLine(index, col int) {
+	line := sb.GetLine(index)
+	if line == nil {
+		return
+	}
+	if col > line.Length+1 {
+		col = line.Length + 1
+	}
+	if col < 1 {
+		col = 1
+	}
+	myState.currentLine = line
+	myState.cursorPos.x = col
+	sb.ShowPos(index, col)
+	sb.ReprintBuffer()
+	cursorToScreen()
+}
 
-	} else if currentLineIndex == sb.DefaultHeight {
-		// load the lines to bottom
-		sb.LoadLine(DOWN, myState.currentLine.Index)
+/* Scrolls the window if the cursor went off screen */
+func scrollToCursor() {
+	// the line may have been spilled and brought back as a new node
+	if line := sb.GetLine(myState.currentLine.Index()); line != nil && line != myState.currentLine {
+		myState.currentLine = line
+	}
+	if sb.ShowPos(myState.currentLine.Index(), myState.cursorPos.x) {
+		sb.ReprintBuffer()
+	}
+}
 
-		currentLine = myState.currentLine
-		nextLine = sb.GetLine(currentLine.Index + num)
+/* Moves the terminal cursor to where the current line and column are drawn */
+func cursorToScreen() {
+	row, col := sb.ScreenPos(myState.currentLine.Index(), myState.cursorPos.x)
+	myState.cursorPos.y = row
+	myState.term.CursorPos(row, col)
+}
 
-		if nextLine == nil {
-			return
-		}
+/* Turns soft wrapping of long lines on and off */
+func toggleWrap() {
+	sb.SetWrap(!sb.Wrap)
+	sb.ShowPos(myState.currentLine.Index(), myState.cursorPos.x)
+	sb.ReprintBuffer()
+	if sb.Wrap {
+		setStatusMessage("Soft wrap on")
+	} else {
+		setStatusMessage("Soft wrap off")
+	}
+	showEditorData()
+}
 
-		if nextLine.Length < currentLine.Length {
-			easyterm.CursorPos(myState.cursorPos.y, nextLine.Length)
-			setCursorPos(myState.cursorPos.y, nextLine.Length+1)
-		}
+func cursorEditPos() screenbuf.EditPos {
+	return screenbuf.EditPos{Line: myState.currentLine.Index(), Col: myState.cursorPos.x}
+}
+
+/* Snapshots the count lines from start that an edit is about to change.
+   Editing drops the selection. */
+func beginEdit(kind, start, count int) *screenbuf.Edit {
+	clearSelection()
+	return &screenbuf.Edit{
+		Kind:     kind,
+		Start:    start,
+		OldLines: sb.LineText(start, count),
+		Before:   cursorEditPos(),
+	}
+}
+
+/* Records the edit now that its lines have become count lines */
+func commitEdit(e *screenbuf.Edit, count int) {
+	e.NewLines = sb.LineText(e.Start, count)
+	e.After = cursorEditPos()
+	sb.Record(e)
+}
 
-		myState.currentLine = nextLine
+func undoEdit() {
+	clearSelection()
+	if e := sb.Undo(); e != nil {
+		gotoLine(e.Before.Line, e.Before.Col)
 	}
 	showEditorData()
 }
 
-func showEditorData() {
-	pos := 30
-	pos2 := 80
-	easyterm.CursorPos(pos, pos2)
-	if myState.currentLine != nil {
-		fmt.Printf("Current Line Index: %v", myState.currentLine.Index)
+func redoEdit() {
+	clearSelection()
+	if e := sb.Redo(); e != nil {
+		gotoLine(e.After.Line, e.After.Col)
 	}
-	easyterm.CursorPos(pos+1, pos2)
-	fmt.Printf("Buffer Length: %v", sb.Size())
-	easyterm.CursorPos(pos+2, pos2)
-	fmt.Print("Position:")
-	easyterm.CursorPos(pos+3, pos2)
-	fmt.Printf("X: %v Y: %v", myState.cursorPos.x, myState.cursorPos.y)
-	easyterm.CursorPos(pos+4, pos2)
-	fmt.Print("DEFAULT_HEIGHT: ")
-	fmt.Print(sb.DefaultHeight)
-	easyterm.CursorPos(pos+5, pos2)
-	fmt.Printf("Index of first visible line: %v", sb.IndexOfFirstVisibleLine)
-	easyterm.CursorPos(pos+6, pos2)
-	fmt.Printf("Index of last visible line: %v", sb.IndexOfLastVisisbleLine)
-	easyterm.CursorPos(myState.cursorPos.y, myState.cursorPos.x)
+	showEditorData()
+}
+
+func writeTextToBuffer(text string) {
+	var (
+		line   string = myState.currentLine.Line
+		offset int    = sb.LineOffset(line, myState.cursorPos.x)
+	)
+	line = line[:offset] + text + line[offset:]
+	sb.SetLine(myState.currentLine, line)
+	sb.RedrawLine(myState.currentLine)
+	myState.cursorPos.x = sb.RealColumn(line, offset+len(text))
+	showEditorData()
 }
 
-func writeTextToBuffer(letter byte) {
+/* Types text at the cursor and records it in the history */
+func insertLetter(text string) {
+	edit := beginEdit(screenbuf.EditInsert, myState.currentLine.Index(), 1)
+	writeTextToBuffer(text)
+	commitEdit(edit, 1)
+	showEditorData()
+}
 
-	switch {
-	// begining
-	case myState.cursorPos.x == 1:
-		myState.currentLine.Line = string(letter) + myState.currentLine.Line
-		myState.currentLine.RealLine = packTabs(myState.currentLine.Line)
-	// end
-	case myState.cursorPos.x == (myState.currentLine.Length + 1):
-		myState.currentLine.Line += string(letter)
-		myState.currentLine.RealLine = packTabs(myState.currentLine.Line)
-	// middle
-	case myState.cursorPos.x < (myState.currentLine.Length + 1):
-		total := make([]rune, myState.currentLine.Length+1)
-		firstHalf := make([]rune, len(myState.currentLine.RealLine[0:myState.cursorPos.x-1]))
-		copy(firstHalf, []rune(myState.currentLine.RealLine[0:myState.cursorPos.x-1]))
-		secondHalf := make([]rune, len(myState.currentLine.RealLine[myState.cursorPos.x-1:myState.currentLine.Length]))
-		copy(secondHalf, []rune(myState.currentLine.RealLine[myState.cursorPos.x-1:myState.currentLine.Length]))
-
-		copy(total, []rune(string(firstHalf)+string(letter)+string(secondHalf)))
-		myState.currentLine.Line = unpackTabs(string(total))
-		myState.currentLine.RealLine = packTabs(myState.currentLine.Line)
-	}
-	easyterm.ClearLine()
-	easyterm.CursorPos(myState.cursorPos.y, 1)
-	fmt.Print(myState.currentLine.Line)
-	easyterm.CursorPos(myState.cursorPos.y, myState.cursorPos.x)
-	myState.currentLine.Length = len(myState.currentLine.RealLine)
-	if letter == 9 {
-		curpos := sb.NextTabStop(myState.cursorPos.x - 1)
-		easyterm.CursorRight(curpos)
-		updateCursorPosX(curpos - (myState.cursorPos.x - 1))
+/* Backspace, records the deleted character or the joined lines */
+func backspaceLetter() {
+	var edit *screenbuf.Edit
+	if prev := sb.PrevLine(myState.currentLine); prev != nil && (myState.currentLine.Length == 0 || myState.cursorPos.x == 1) {
+		// the line is going to be joined with the one above
+		edit = beginEdit(screenbuf.EditJoin, prev.Index(), 2)
 	} else {
-		easyterm.CursorPos(myState.cursorPos.y, myState.cursorPos.x+1)
-		updateCursorPosX(1)
+		edit = beginEdit(screenbuf.EditDelete, myState.currentLine.Index(), 1)
 	}
+	backspaceLine()
+	commitEdit(edit, 1)
 	showEditorData()
 }
 
@@ -381,237 +505,120 @@ func backspaceLine() {
 		}
 	}*/
 
-	if myState.currentLine.Length == 0 {
-		// delete node, rehook list ends and move cursor to end of previous line
-		var (
-			prev *BufferNode
-			next *BufferNode
-		)
-		prev = myState.currentLine.Prev
-		next = myState.currentLine.Next
+	if len(myState.currentLine.Line) == 0 {
+		// delete node and move cursor to end of previous line
+		var prev *BufferNode
+		prev = sb.PrevLine(myState.currentLine)
 
 		if prev != nil {
 			// verify that if we are working with the first visible line we update the buffer
-			if myState.currentLine.Index == sb.IndexOfFirstVisibleLine {
+			if myState.currentLine.Index() == sb.IndexOfFirstVisibleLine {
 				sb.IndexOfFirstVisibleLine -= 1
 			}
-			prev.Next = next
 		} else {
 			return
 		}
 
-		if next != nil {
-			next.Prev = prev
-		}
-
-		myState.currentLine.Prev = nil
-		myState.currentLine.Next = nil
-
+		sb.DeleteLine(myState.currentLine)
 		myState.currentLine = prev
 
-		updateCursorPosY(-1) // move up one line in state
-		sb.UpdateBufferIndexes()
-		// When we delete a line we can load the next one on screen if any in sb, else load if we have any
-		lastLineOnScreen := sb.GetLine(sb.IndexOfLastVisisbleLine - 1)
-		if lastLineOnScreen != nil {
-			if lastLineOnScreen.Next == nil {
-				sb.LoadLine(DOWN, lastLineOnScreen.Index)
-			} else {
-				sb.IndexOfLastVisisbleLine = lastLineOnScreen.Next.Index
-			}
-		}
+		// the window fills itself up with the lines below when reprinted
 		sb.ReprintBuffer() // reprints complete buffer
-		if myState.cursorPos.y > sb.DefaultHeight {
-			var ypos int = sb.DefaultHeight - (myState.cursorPos.y - sb.DefaultHeight)
-			easyterm.CursorPos(ypos, myState.currentLine.Length+1)
-			setCursorPos(ypos, myState.currentLine.Length+1)
-		} else {
-			easyterm.CursorPos(myState.cursorPos.y, myState.currentLine.Length+1)
-			setCursorPos(myState.cursorPos.y, myState.currentLine.Length+1)
-		}
+		myState.cursorPos.x = myState.currentLine.Length + 1
 		showEditorData()
 		return
 	}
 
-	// TODO: single line wrapping handling
-
 	switch {
-	// Between the first and last characters of a line
-	case myState.cursorPos.x > 1 && myState.cursorPos.x < (myState.currentLine.Length+1):
-
-		var firstHalf []rune
-		var total []rune
-		var pos int = myState.cursorPos.x - 1
-		var moveCursor int = 0
-
-		if sb.IsATabStop(pos) {
-			// if it's a tab stop then go from that tabstop to the prev one and check if
-			// there are any tabs to remove
-			if strings.ContainsRune(myState.currentLine.RealLine[sb.PrevTabStop(pos):pos], 9){
-				for i:= pos-1; i >= 0; i-- {
-					if myState.currentLine.RealLine[i] == '\t' {
-						// tab at the beginning
-						if i == 0 {
-							moveCursor = pos
-							pos = 0
-							break	
-						}
-						pos = (pos - i) // to land on the char before the stop
-						moveCursor = pos
-						break
-					}
-				}
-				total = make([]rune, myState.currentLine.Length-pos)
-				firstHalf = make([]rune, len(myState.currentLine.RealLine[0:pos]))
-				copy(firstHalf, []rune(myState.currentLine.RealLine[0:pos]))
-			} else {
-				total = make([]rune, myState.currentLine.Length-1)
-				firstHalf = make([]rune, len(myState.currentLine.RealLine[0:myState.cursorPos.x-2]))
-				copy(firstHalf, []rune(myState.currentLine.RealLine[0:myState.cursorPos.x-2]))
-				moveCursor = 1
-			}
-			
-		} else {
-			total = make([]rune, myState.currentLine.Length-1)
-			firstHalf = make([]rune, len(myState.currentLine.RealLine[0:myState.cursorPos.x-2]))
-			copy(firstHalf, []rune(myState.currentLine.RealLine[0:myState.cursorPos.x-2]))
-			moveCursor = 1
-		}
-		
-		secondHalf := make([]rune, len(myState.currentLine.RealLine[myState.cursorPos.x-1:myState.currentLine.Length]))
-		copy(secondHalf, []rune(myState.currentLine.RealLine[myState.cursorPos.x-1:myState.currentLine.Length]))
-
-		copy(total, []rune(string(firstHalf)+string(secondHalf)))
-		myState.currentLine.Line = unpackTabs(string(total))
-		myState.currentLine.RealLine = packTabs(myState.currentLine.Line)
-		myState.currentLine.Length = len(myState.currentLine.RealLine)
-
-		// reprint line
-		easyterm.CursorPos(myState.cursorPos.y, 1) // move cursor to start
-		easyterm.ClearLine()
-		easyterm.CursorPos(myState.cursorPos.y, 1) // move cursor to start
-		fmt.Print(myState.currentLine.Line)        // write updated line again
-		easyterm.CursorPos(myState.cursorPos.y, myState.cursorPos.x)
-		// move & update cursor
-		easyterm.CursorLeft(moveCursor)
-		updateCursorPosX(-1 * moveCursor)
+	// Somewhere after the first character of a line
+	case myState.cursorPos.x > 1:
+		var (
+			line   string = myState.currentLine.Line
+			offset int    = sb.LineOffset(line, myState.cursorPos.x)
+			prev   int    = screenbuf.PrevOffset(line, offset)
+		)
+		// a tab or a character with its combining marks goes in one go
+		line = line[:prev] + line[offset:]
+		sb.SetLine(myState.currentLine, line)
+		sb.RedrawLine(myState.currentLine)
+		myState.cursorPos.x = sb.RealColumn(line, prev)
 
 	// At the start of a line
 	case myState.cursorPos.x == 1:
 
 		// check if we have line on top, if not do nothing, else move it up
 		var prev *BufferNode
-		prev = myState.currentLine.Prev
+		prev = sb.PrevLine(myState.currentLine)
 		if prev != nil {
 			// verify that if we are working with the first visible line we update the buffer
-			if myState.currentLine.Index == sb.IndexOfFirstVisibleLine {
+			if myState.currentLine.Index() == sb.IndexOfFirstVisibleLine {
 				sb.IndexOfFirstVisibleLine -= 1
 				sb.IndexOfLastVisisbleLine -= 1
 			}
 			// move current line up
 			origPrevLineLength := prev.Length // to move the cursor later
-			currentText := make([]rune, myState.currentLine.Length)
-			copy(currentText, []rune(myState.currentLine.RealLine))
-			prev.Line += unpackTabs(string(currentText))
-			prev.RealLine = packTabs(prev.Line)
-			prev.Length = len(prev.RealLine)
-
-			// rehook list, sans the soon to be destroyed node and update currentLine state
-			var next *BufferNode
-			next = myState.currentLine.Next
-			if next != nil {
-				prev.Next = next
-				next.Prev = prev
-				myState.currentLine.Prev = nil
-				myState.currentLine.Next = nil
-			} else {
-				prev.Next = nil
-				myState.currentLine.Prev = nil
-			}
+			sb.SetLine(prev, prev.Line+myState.currentLine.Line)
 
+			// drop the joined line and update currentLine state
+			sb.DeleteLine(myState.currentLine)
 			myState.currentLine = prev
-			updateCursorPosY(-1) // move up one line in state
-			/*easyterm.CursorPos(myState.cursorPos.y, 1) // move cursor to start of new line
-			easyterm.ClearLine()
-			easyterm.CursorPos(myState.cursorPos.y, 1) // move cursor to start
-			fmt.Print(myState.currentLine.line) // write updated line again*/
-			sb.UpdateBufferIndexes()
-			// When we delete a line we can load the next one on screen if any in sb, else load if we have any
-			lastLineOnScreen := sb.GetLine(sb.IndexOfLastVisisbleLine - 1)
-			if lastLineOnScreen != nil {
-				if lastLineOnScreen.Next == nil {
-					sb.LoadLine(DOWN, lastLineOnScreen.Index)
-				} else {
-					sb.IndexOfLastVisisbleLine = lastLineOnScreen.Next.Index
-				}
-			}
+			// the window fills itself up with the lines below when reprinted
 			sb.ReprintBuffer() // reprints complete buffer
-			easyterm.CursorPos(myState.cursorPos.y, origPrevLineLength+1)
-			setCursorPos(myState.cursorPos.y, origPrevLineLength+1)
-			//easyterm.CursorPos(20, 1)
-			//fmt.Print(sbGetBufferLength())
-
+			myState.cursorPos.x = origPrevLineLength + 1
 		}
-		// At the very end of a line
-	case myState.cursorPos.x == (myState.currentLine.Length + 1):
-
-		var newLine []rune
-		var pos int = myState.cursorPos.x - 1
-		var moveCursor int = 0
-
-		if sb.IsATabStop(pos) {
-
-			if strings.ContainsRune(myState.currentLine.RealLine[sb.PrevTabStop(pos):pos], 9){
-				for i:= pos - 1; i >= 0; i-- {
-					if myState.currentLine.RealLine[i] == '\t' {
-						pos = (pos - i) // to land on the char before the stop
-						break
-					}
-				}
-				moveCursor = pos
-				newLine = make([]rune, myState.currentLine.Length-pos)
-				copy(newLine, []rune(myState.currentLine.RealLine[0:myState.currentLine.Length-pos]))
-			} else {
-				moveCursor = 1
-				newLine = make([]rune, myState.currentLine.Length-1)
-				copy(newLine, []rune(myState.currentLine.RealLine[0:myState.currentLine.Length-1]))
-			}
-		} else {
-			moveCursor = 1
-			newLine = make([]rune, myState.currentLine.Length-1)
-			copy(newLine, []rune(myState.currentLine.RealLine[0:myState.currentLine.Length-1]))
-		}
-
-		//newLine := make([]rune, myState.currentLine.Length-1)
-		//copy(newLine, []rune(myState.currentLine.RealLine[0:myState.currentLine.Length-1]))
-		myState.currentLine.Line = unpackTabs(string(newLine))
-		myState.currentLine.RealLine = packTabs(myState.currentLine.Line)
-		myState.currentLine.Length = len(myState.currentLine.RealLine)
-
-		// reprint line
-		easyterm.CursorPos(myState.cursorPos.y, 1) // move cursor to start
-		easyterm.ClearLine()
-		easyterm.CursorPos(myState.cursorPos.y, 1) // move cursor to start
-		fmt.Print(myState.currentLine.Line)        // write updated line again
-		easyterm.CursorPos(myState.cursorPos.y, myState.cursorPos.x)
-		// move & update cursor
-		easyterm.CursorLeft(moveCursor)
-		updateCursorPosX(-1 * moveCursor)
 	}
 	showEditorData()
 }
 
-func packTabs(line string) string {
-	return sb.PackTabs(line)
+/* Saves the buffer and says how it went. Returns true when Ctrl-Q was
+   pressed at the file name prompt, the caller decides about quitting. */
+func saveFile() bool {
+	var wasDirty bool = sb.Dirty
+	n, err := sb.Save()
+	switch {
+	case err == screenbuf.ErrQuit:
+		return true
+	case err != nil:
+		setStatusMessage("-winter: %v", err)
+	case !wasDirty:
+		setStatusMessage("No changes to save")
+	case sb.Dirty:
+		setStatusMessage("Save cancelled")
+	default:
+		setStatusMessage("Saved file: \"%v\". Bytes Written: %v", sb.FilePath+"/"+sb.FileName, n)
+	}
+	showEditorData()
+	return false
 }
 
-func unpackTabs(line string) string {
-	return sb.UnpackTabs(line)
+/* Clears the screen and gives the terminal back, winter is done */
+func endEditor() {
+	myState.term.Clear()
+	myState.term.CursorPos(1, 1)
+	myState.term.End()
 }
 
-func saveFile() {
-	sb.Save()
-	easyterm.CursorPos(myState.cursorPos.y, myState.cursorPos.x)
+/* Asks what to do with unsaved changes before quitting. Returns true when
+   it's fine to quit, pressing Ctrl-Q again at the prompt quits anyway. */
+func confirmQuit() bool {
+	if !sb.Dirty {
+		return true
+	}
+	for {
+		switch promptKey("Save changes? (y/n/cancel) ") {
+		case 'y', 'Y':
+			if saveFile() {
+				return true
+			}
+			// a failed or cancelled save keeps the editor open
+			return !sb.Dirty
+		case 'n', 'N', keyQuit:
+			return true
+		case 'c', 'C', 27:
+			setStatusMessage("Quit cancelled")
+			return false
+		}
+	}
 }
 
 // TODO: When the file is new or temp, don't actually create the file until it's saved by user
@@ -650,162 +657,217 @@ func handleArguments(args []string) (*File, error) {
 }
 
 func main() {
-	/* Terminal in raw mode */
-	easyterm.Init()
-	easyterm.Clear()
-	easyterm.CursorPos(1, 1)
+	/* Terminal in raw mode, drawing goes through a frame that sends only
+	   what changed, each Flush in one write to stdout */
+	renderer := easyterm.NewRenderer(easyterm.StdTerminal{}, os.Stdout)
+	renderer.RuneWidth = screenbuf.RuneWidth
+	myState.term = renderer
+	myState.keys = easyterm.NewKeyReader(myState.term)
+	myState.term.Init()
+	handleSignals()
+	myState.term.Clear()
+	myState.term.CursorPos(1, 1)
 
 	/* Init my position */
 	myState.cursorPos = Cursor{1, 1}
 
-	/* Reader and Writer to standard in & out */
-	termRW = bufio.NewReadWriter(bufio.NewReader(os.Stdin), bufio.NewWriter(os.Stdout))
-
-	/* Vars for holding data */
-	var (
-		buffer []byte
-	)
-
-	/* Init those vars */
-	buffer = make([]byte, 4) // Length of an int var
-
 	// Handles file name, myState.filePtr should have been set
 	file, err := handleArguments(os.Args)
 	if err == nil {
 		/* Init the ScreenBuffer */
-		sb = screenbuf.NewScreenBuffer(file)
+		sb = screenbuf.NewScreenBuffer(file, myState.term)
 		sb.LoadFile()
 	} else {
 		// If the file doesn't exist or if something went wrong
 		if nwerr, ok := err.(*WinterError); ok && nwerr.IsNewFile() {
-			sb = screenbuf.NewScreenBuffer(nil)
+			sb = screenbuf.NewScreenBuffer(nil, myState.term)
 			sb.FileName = myState.fileName
 			sb.FilePath = myState.filePath
 			sb.LoadFile()
 		} else {
-			fmt.Print("-winter: ")
-			fmt.Println(err)
-			easyterm.CursorPos(2, 1)
-			easyterm.End()
+			fmt.Fprint(myState.term, "-winter: ")
+			fmt.Fprintln(myState.term, err)
+			myState.term.CursorPos(2, 1)
+			myState.term.End()
 			os.Exit(1)
 		}
 	}
+	sb.OnResize = handleResize
+	sb.Keys = myState.keys
 	sb.PrintBuffer()
 	myState.cursorPos.x = 1
 	myState.cursorPos.y = 1
 	/* Get first line node to have something to write to */
 	myState.currentLine = sb.GetLine(1)
+	setStatusMessage("HELP: Ctrl-S = save | Ctrl-Q = quit | Ctrl-F = find | Ctrl-_ = undo | Ctrl-Y = redo | Ctrl-Z = suspend")
 	showEditorData()
 
 	for {
-		if bytesRead, err := termRW.Reader.Read(buffer); err == nil {
-			//fmt.Printf("%s", string(letter))
-			/* Means that the arrow keys where pressed */
-			/* This will send 3 bytes: Esc, [ and (A or B or C or D)  */
-			if bytesRead > 1 {
-				//fmt.Print(string(buffer[0]))
-				if buffer[0] == 27 && buffer[1] == 91 {
-
-					switch buffer[2] {
-
-					case 68:
-						// Left arrow
-						moveCursorX(-1, easyterm.CursorLeft)
-						//easyterm.CursorLeft(1)
-						//updateCursorPosX(-1)
-
-					case 65:
-						// Up arrow
-						moveCursorY(-1, easyterm.CursorUp)
-						//easyterm.CursorUp(1)
-						//updateCursorPosY(1)
-
-					case 67:
-						// Right arrow
-						moveCursorX(1, easyterm.CursorRight)
-						//easyterm.CursorRight(1)
-						//updateCursorPosX(1)
-
-					case 66:
-						// Down arrow
-						moveCursorY(1, easyterm.CursorDown)
-						//easyterm.CursorDown(1)
-						//updateCursorPosY(-1)
+		key, err := myState.keys.ReadKey()
+		if err == easyterm.ErrResize {
+			handleResize()
+			continue
+		} else if err != nil {
+			// stdin is gone, most likely along with the terminal
+			shutdown("input closed", 0)
+		}
 
-					}
-					clearBuffer(buffer)
-				} // If not [ ignore the Esc
+		selectFor(key)
+		yankFor(key)
+		switch key.Code {
+		case easyterm.KeyLeft:
+			if key.Mod&(easyterm.ModCtrl|easyterm.ModAlt) != 0 {
+				moveWord(false)
+			} else {
+				moveCursorX(-1)
+			}
+
+		case easyterm.KeyUp:
+			moveCursorY(-1)
+
+		case easyterm.KeyRight:
+			if key.Mod&(easyterm.ModCtrl|easyterm.ModAlt) != 0 {
+				moveWord(true)
+			} else {
+				moveCursorX(1)
+			}
+
+		case easyterm.KeyDown:
+			moveCursorY(1)
+
+		case easyterm.KeyHome:
+			if key.Mod&easyterm.ModCtrl != 0 {
+				moveToTop()
+			} else {
+				moveCursorHome()
+			}
+
+		case easyterm.KeyEnd:
+			if key.Mod&easyterm.ModCtrl != 0 {
+				moveToBottom()
+			} else {
+				moveCursorEnd()
+			}
+
+		case easyterm.KeyPgUp:
+			movePage(-1)
+
+		case easyterm.KeyPgDn:
+			movePage(1)
 
+		case easyterm.KeyDelete:
+			if !deleteSelection() {
+				deleteLetter()
+			}
+
+		case easyterm.KeyEnter:
+			var oldLineIndex int = myState.currentLine.Index()
+			edit := beginEdit(screenbuf.EditSplit, oldLineIndex, 1)
+			sb.AddLineToBuffer(myState.currentLine.Index(), myState.cursorPos.x, myState.cursorPos.y)
+			myState.cursorPos.x = 1
+			myState.currentLine = sb.GetLine(oldLineIndex + 1)
+			commitEdit(edit, 2)
+			showEditorData()
+
+		case easyterm.KeyBackspace:
+			if deleteSelection() {
+				break
+			} else if key.Mod&easyterm.ModAlt != 0 {
+				deleteWord(false)
 			} else {
+				backspaceLetter()
+			}
+
+		case easyterm.KeyTab:
+			if key.Mod == 0 {
+				insertLetter("\t")
+			}
+
+		case easyterm.KeyEscape:
+			clearSelection()
+			showEditorData()
 
-				letter := buffer[0]
-				//fmt.Print(letter)
-				switch {
-				case letter == 13:
-					// Enter
-					//easyterm.CursorNextLine(1)
-					//updateCursorPosY(1)
-					var oldLineIndex int = myState.currentLine.Index
-					sb.AddLineToBuffer(myState.currentLine.Index, myState.cursorPos.x, myState.cursorPos.y)
-					//myState.currentLine = sb.GetLine(newIndex)
-					sb.Dirty = true
-					if myState.cursorPos.y < sb.DefaultHeight {
-						updateCursorPosY(1)
+		case easyterm.KeyPaste:
+			insertPaste(key.Text)
+
+		case easyterm.KeyMouse:
+			handleMouse(key.Mouse)
+
+		case easyterm.KeyRune:
+			// Alt with a letter is left for commands
+			if key.Mod == easyterm.ModAlt && key.Rune == keyDeleteNextWord {
+				deleteWord(true)
+			} else if key.Mod == easyterm.ModAlt && key.Rune == keyYankPop {
+				yankPop()
+			} else if key.Mod == 0 && (unicode.IsPrint(key.Rune) || unicode.Is(unicode.Mn, key.Rune)) {
+				insertLetter(string(key.Rune))
+			}
+
+		case easyterm.KeyCtrl:
+			if key.Mod != 0 {
+				break
+			}
+			switch letter := byte(key.Rune); {
+			case letter == 19:
+				// Save, Ctrl-Q at the file name prompt asks to quit
+				if saveFile() {
+					if !confirmQuit() {
+						showEditorData()
+						break
 					}
-					easyterm.CursorPos(myState.cursorPos.y, 1)
-					setCursorPos(myState.cursorPos.y, 1)
-					myState.currentLine = sb.GetLine(oldLineIndex + 1)
-					showEditorData()
-					//fmt.Println(sbGetBufferLength())
-					//myState.currentLine = sbGetLine(myState.cursorPos.y)
-					//easyterm.CursorPos(myState.cursorPos.y, 1)
-					//myState.currentLine = sbGetLine(myState.cursorPos.y)
-					//fmt.Print(myState.cursorPos.x)
-					//fmt.Print(myState.cursorPos.y)
-				case letter == 127:
-					// Backspace
-					//easyterm.CursorLeft(1)
-					//updateCursorPosX(-1)
-					//easyterm.ClearFromCursor()
-					backspaceLine()
-					sb.Dirty = true
-				case letter == 27:
-					// Do Nothing for Esc for now
-
-				case letter == 19:
-					// Save
-					saveFile()
-
-				case letter == 17:
-					// Ctrl-Q
-					easyterm.Clear()
-					easyterm.CursorPos(1, 1)
-					easyterm.End()
+					endEditor()
 					return
+				}
 
-				case letter == 9:
-					// Tab
-					writeTextToBuffer(letter)
-					sb.Dirty = true
-				case letter > 0 && letter <= 31:
-					// Do nothing
-
-				default:
-					//fmt.Print(letter)
-					///fmt.Printf("BytesRead: %d\n", bytesRead)
-					//fmt.Printf("%s", string(letter))
-					//updateCursorPosX(1)
-					writeTextToBuffer(letter)
-					sb.Dirty = true
+			case letter == keyQuit:
+				if !confirmQuit() {
+					showEditorData()
+					break
 				}
+				endEditor()
+				return
 
-			}
+			case letter == keySuspend:
+				suspend()
 
-		} else {
-			easyterm.Clear()
-			easyterm.CursorPos(1, 1)
-			easyterm.End()
-			return
+			case letter == keyUndo:
+				undoEdit()
+
+			case letter == keyRedo:
+				redoEdit()
+
+			case letter == keyFind:
+				handleSearch()
+
+			case letter == keyReplace:
+				handleReplace()
+
+			case letter == keyWrap:
+				toggleWrap()
+
+			case letter == keyCut:
+				cutText()
+
+			case letter == keyCopy:
+				copyText()
+
+			case letter == keyPaste:
+				pasteText()
+
+			case letter == keyGoto:
+				handleGoto()
+
+			case letter == keyMark:
+				toggleMark()
+
+			case letter == keyDeleteWord:
+				deleteWord(false)
+
+			case letter == keyDebug:
+				showDebug = !showDebug
+				sb.ReprintBuffer()
+				showEditorData()
+			}
 		}
 	}
 
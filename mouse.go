@@ -0,0 +1,86 @@
+package main
+
+import (
+	"easyterm"
+)
+
+/* Lines the view scrolls for every notch of the wheel */
+const WHEEL_LINES int = 3
+
+/* Clicking puts the cursor where the pointer is, dragging selects from
+   there and the wheel scrolls the view */
+func handleMouse(m easyterm.Mouse) {
+	switch m.Action {
+	case easyterm.MousePress:
+		if m.Button != easyterm.MouseLeft {
+			return
+		}
+		clearSelection()
+		if moveToScreen(m.Y, m.X) {
+			// a drag selects from here, a plain click drops it on release
+			setAnchor(false)
+		}
+	case easyterm.MouseDrag:
+		if m.Button != easyterm.MouseLeft || myState.anchor == nil {
+			return
+		}
+		// past the top or bottom row the view follows the pointer
+		if m.Y < 1 {
+			sb.LoadLine(UP, sb.IndexOfFirstVisibleLine)
+			m.Y = 1
+		} else if m.Y > sb.VisibleRows() {
+			sb.LoadLine(DOWN, sb.IndexOfLastVisisbleLine)
+			m.Y = sb.VisibleRows()
+		}
+		moveToScreen(m.Y, m.X)
+	case easyterm.MouseRelease:
+		if _, ok := selectedRegion(); !ok {
+			clearSelection()
+			showEditorData()
+		}
+	case easyterm.MouseWheelUp:
+		scrollView(-WHEEL_LINES)
+	case easyterm.MouseWheelDown:
+		scrollView(WHEEL_LINES)
+	}
+}
+
+/* Puts the cursor on what is drawn at screen row y and column x, false when
+   there is no text there */
+func moveToScreen(y, x int) bool {
+	index, col, ok := sb.PosAt(y, x)
+	if !ok {
+		return false
+	}
+	myState.currentLine = sb.GetLine(index)
+	myState.cursorPos.x = col
+	showEditorData()
+	return true
+}
+
+/* Scrolls the view by lines, down when positive. The cursor stays where it
+   is in the text unless that goes off screen, then it moves to the nearest
+   line still showing. */
+func scrollView(lines int) {
+	for ; lines > 0; lines-- {
+		sb.LoadLine(DOWN, sb.IndexOfLastVisisbleLine)
+	}
+	for ; lines < 0; lines++ {
+		sb.LoadLine(UP, sb.IndexOfFirstVisibleLine)
+	}
+	var index int = myState.currentLine.Index()
+	if index < sb.IndexOfFirstVisibleLine {
+		index = sb.IndexOfFirstVisibleLine
+	} else if index > sb.IndexOfLastVisisbleLine {
+		index = sb.IndexOfLastVisisbleLine
+	}
+	if line := sb.GetLine(index); line != nil && line != myState.currentLine {
+		var col int = myState.cursorPos.x
+		if col > line.Length+1 {
+			col = line.Length + 1
+		}
+		myState.currentLine = line
+		myState.cursorPos.x = sb.SnapColumn(line, col)
+	}
+	showEditorData()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"easyterm"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+/* Writes text on the bottom row of the screen */
+func drawPrompt(text string) {
+	myState.term.CursorPos(sb.DefaultHeight, 1)
+	myState.term.ClearLine()
+	fmt.Fprint(myState.term, text)
+}
+
+/* What of a paste fits in a one line prompt */
+func firstLine(text string) string {
+	if i := strings.IndexAny(text, "\r\n"); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
+
+/* Reads a line of text on the bottom row, returns false when Esc is pressed */
+func promptLine(label string) (string, bool) {
+	var text string
+	drawPrompt(label)
+	for {
+		key, err := myState.keys.ReadKey()
+		if err == easyterm.ErrResize {
+			handleResize()
+			drawPrompt(label + text)
+			continue
+		} else if err != nil {
+			return "", false
+		}
+		switch key.Code {
+		case easyterm.KeyEnter:
+			drawPrompt("")
+			return text, true
+		case easyterm.KeyEscape:
+			drawPrompt("")
+			return "", false
+		case easyterm.KeyBackspace:
+			if len(text) > 0 {
+				_, size := utf8.DecodeLastRuneInString(text)
+				text = text[:len(text)-size]
+			}
+		case easyterm.KeyRune:
+			if key.Mod == 0 {
+				text += string(key.Rune)
+			}
+		case easyterm.KeyPaste:
+			text += firstLine(key.Text)
+		default:
+			// arrows and the like mean nothing here
+			continue
+		}
+		drawPrompt(label + text)
+	}
+}
+
+/* Asks a question on the bottom row and returns the first key pressed, Esc
+   as 27 and other control keys as their code */
+func promptKey(label string) byte {
+	drawPrompt(label)
+	for {
+		key, err := myState.keys.ReadKey()
+		if err == easyterm.ErrResize {
+			handleResize()
+			drawPrompt(label)
+			continue
+		} else if err != nil {
+			return 27
+		}
+		var letter byte
+		switch {
+		case key.Code == easyterm.KeyEscape:
+			letter = 27
+		case key.Code == easyterm.KeyEnter:
+			letter = 13
+		case key.Code == easyterm.KeyCtrl:
+			letter = byte(key.Rune)
+		case key.Code == easyterm.KeyRune && key.Rune < utf8.RuneSelf:
+			letter = byte(key.Rune)
+		default:
+			continue
+		}
+		drawPrompt("")
+		return letter
+	}
+}
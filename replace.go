@@ -0,0 +1,164 @@
+package main
+
+import (
+	"regexp"
+	"screenbuf"
+	"strconv"
+	"strings"
+)
+
+/* Answers to the per match question of a replace */
+const (
+	REPLACE_ASK = iota
+	REPLACE_ALL
+	REPLACE_QUIT
+)
+
+/* Asks for a regexp, a replacement template and a range of lines, then goes
+   through every match asking if it should be replaced */
+func handleReplace() {
+	pattern, ok := promptLine("Replace regexp: ")
+	if !ok || len(pattern) == 0 {
+		showEditorData()
+		return
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		setStatusMessage("-winter: %v", err)
+		showEditorData()
+		return
+	}
+	template, ok := promptLine("Replace with: ")
+	if !ok {
+		showEditorData()
+		return
+	}
+	from, to, ok := promptRange()
+	if !ok {
+		showEditorData()
+		return
+	}
+
+	var (
+		mode     = REPLACE_ASK
+		replaced int
+		last     = cursorEditPos()
+		// only the lines that change go in the history, as a single step
+		edit = &screenbuf.Edit{Kind: screenbuf.EditReplace, Before: last}
+	)
+	clearSelection()
+	for node := sb.GetLine(from); node != nil && node.Index() <= to && mode != REPLACE_QUIT; node = sb.GetLine(node.Index() + 1) {
+		var (
+			n    int
+			orig string = node.Line
+		)
+		n, mode = replaceInLine(node, re, template, mode, &last)
+		replaced += n
+		if node.Line != orig {
+			edit.Parts = append(edit.Parts, &screenbuf.Edit{
+				Kind:     screenbuf.EditReplace,
+				Start:    node.Index(),
+				OldLines: []string{orig},
+				NewLines: []string{node.Line},
+			})
+		}
+	}
+	sb.Marked = nil
+	gotoLine(last.Line, last.Col)
+	if len(edit.Parts) > 0 {
+		edit.After = cursorEditPos()
+		sb.Record(edit)
+	}
+	setStatusMessage("Replaced %d match(es)", replaced)
+	showEditorData()
+}
+
+/* Replaces the matches of re in node, asking first unless mode says not to.
+   Returns the amount of replacements and the mode to carry on with */
+func replaceInLine(node *BufferNode, re *regexp.Regexp, template string, mode int, last *screenbuf.EditPos) (int, int) {
+	var (
+		orig     string = node.Line
+		out      []byte
+		prev     int = 0
+		replaced int = 0
+	)
+	for _, m := range re.FindAllStringSubmatchIndex(orig, -1) {
+		out = append(out, orig[prev:m[0]]...)
+		if mode == REPLACE_ASK {
+			// show the line as it is so far with the match marked
+			sb.SetLine(node, string(out)+orig[m[0]:])
+			sb.Marked = []screenbuf.Span{{Line: node.Index(), Start: len(out), End: len(out) + m[1] - m[0]}}
+			gotoLine(node.Index(), sb.RealColumn(node.Line, len(out)))
+			drawStatusBar()
+			switch promptKey("Replace? (y/n/a/q): ") {
+			case 'y', 'Y':
+			case 'a', 'A':
+				mode = REPLACE_ALL
+			case 'n', 'N':
+				out = append(out, orig[m[0]:m[1]]...)
+				prev = m[1]
+				continue
+			default:
+				mode = REPLACE_QUIT
+			}
+		}
+		if mode == REPLACE_QUIT {
+			prev = m[0]
+			break
+		}
+		out = re.ExpandString(out, template, orig, m)
+		*last = screenbuf.EditPos{Line: node.Index(), Col: sb.RealColumn(string(out), len(out))}
+		prev = m[1]
+		replaced++
+	}
+	out = append(out, orig[prev:]...)
+	sb.SetLine(node, string(out))
+	return replaced, mode
+}
+
+/* Asks which lines to work on. The answer is "l" for the current line, a
+   "from,to" pair of line numbers or nothing at all for the whole buffer.
+   With text selected there is nothing to ask, its lines are the range. */
+func promptRange() (int, int, bool) {
+	if r, ok := selectedRegion(); ok {
+		var to int = r.End.Line
+		if r.End.Col == 1 && to > r.Start.Line {
+			// nothing of the last line is selected
+			to--
+		}
+		return r.Start.Line, to, true
+	}
+	answer, ok := promptLine("In (l)ine, from,to or whole buffer: ")
+	if !ok {
+		return 0, 0, false
+	}
+	answer = strings.TrimSpace(answer)
+	switch {
+	case answer == "l" || answer == "L":
+		return myState.currentLine.Index(), myState.currentLine.Index(), true
+	case answer == "":
+		sb.LoadAll()
+		return 1, sb.Size(), true
+	}
+	bounds := strings.Split(answer, ",")
+	if len(bounds) != 2 {
+		setStatusMessage("-winter: Invalid range %v", answer)
+		return 0, 0, false
+	}
+	from, ferr := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	to, terr := strconv.Atoi(strings.TrimSpace(bounds[1]))
+	if ferr != nil || terr != nil || from < 1 || to < from {
+		setStatusMessage("-winter: Invalid range %v", answer)
+		return 0, 0, false
+	}
+	for sb.Size() < to && sb.ReadLine() {
+	}
+	if to > sb.Size() {
+		to = sb.Size()
+	}
+	if from > to {
+		setStatusMessage("-winter: Invalid range %v", answer)
+		return 0, 0, false
+	}
+	return from, to, true
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"easyterm"
+	"fmt"
+	"screenbuf"
+	"unicode/utf8"
+)
+
+/* Incremental search, the prompt lives on the bottom row like the save prompt */
+func handleSearch() {
+	var (
+		query        string
+		origin       = cursorEditPos()
+		firstVisible = sb.IndexOfFirstVisibleLine
+		firstRow     = sb.FirstVisibleRow
+		colOffset    = sb.ColOffset
+		match        = origin
+		found        bool
+	)
+
+	// jumps to the next match in the given direction
+	step := func(forward bool) {
+		if !found {
+			return
+		}
+		from := match
+		if forward {
+			from.Col++
+		}
+		match, found = sb.Find(query, from, forward)
+		showSearch(query, match, found)
+	}
+
+	showSearch(query, match, false)
+	for {
+		key, err := myState.keys.ReadKey()
+		if err == easyterm.ErrResize {
+			handleResize()
+			showSearch(query, match, found)
+			continue
+		} else if err != nil {
+			break
+		}
+
+		switch key.Code {
+		case easyterm.KeyDown, easyterm.KeyRight:
+			step(true)
+		case easyterm.KeyUp, easyterm.KeyLeft:
+			step(false)
+		case easyterm.KeyEnter:
+			// stay on the match
+			sb.Highlight = ""
+			if found {
+				gotoLine(match.Line, match.Col)
+			} else {
+				gotoLine(origin.Line, origin.Col)
+			}
+			showEditorData()
+			return
+		case easyterm.KeyEscape:
+			// back to where we started
+			sb.Highlight = ""
+			sb.IndexOfFirstVisibleLine = firstVisible
+			sb.FirstVisibleRow = firstRow
+			sb.ColOffset = colOffset
+			gotoLine(origin.Line, origin.Col)
+			showEditorData()
+			return
+		case easyterm.KeyBackspace:
+			if len(query) > 0 {
+				_, size := utf8.DecodeLastRuneInString(query)
+				query = query[:len(query)-size]
+				match, found = sb.Find(query, origin, true)
+				showSearch(query, match, found)
+			}
+		case easyterm.KeyCtrl:
+			switch key.Rune {
+			case 14:
+				// Ctrl-N
+				step(true)
+			case 16:
+				// Ctrl-P
+				step(false)
+			}
+		case easyterm.KeyRune:
+			if key.Mod == 0 {
+				query += string(key.Rune)
+				match, found = sb.Find(query, origin, true)
+				showSearch(query, match, found)
+			}
+		case easyterm.KeyPaste:
+			query += firstLine(key.Text)
+			match, found = sb.Find(query, origin, true)
+			showSearch(query, match, found)
+		}
+	}
+}
+
+/* Scrolls to the match, highlights every occurrence and redraws the prompt */
+func showSearch(query string, match screenbuf.EditPos, found bool) {
+	sb.Highlight = query
+	if found {
+		gotoLine(match.Line, match.Col)
+	} else {
+		sb.ReprintBuffer()
+	}
+	drawStatusBar()
+	myState.term.CursorPos(sb.DefaultHeight, 1)
+	myState.term.ClearLine()
+	fmt.Fprint(myState.term, "Search: "+query)
+	if !found && len(query) > 0 {
+		fmt.Fprint(myState.term, " (not found)")
+		myState.term.CursorLeft(len(" (not found)"))
+	}
+}
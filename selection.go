@@ -0,0 +1,135 @@
+package main
+
+import (
+	"easyterm"
+	"screenbuf"
+	"strings"
+)
+
+/* Tells if a key only moves the cursor, those are the ones that select with
+   Shift held */
+func isMovement(code easyterm.KeyCode) bool {
+	switch code {
+	case easyterm.KeyUp, easyterm.KeyDown, easyterm.KeyLeft, easyterm.KeyRight,
+		easyterm.KeyHome, easyterm.KeyEnd, easyterm.KeyPgUp, easyterm.KeyPgDn:
+		return true
+	}
+	return false
+}
+
+/* Runs before every key. Moving with Shift held starts a selection where the
+   cursor is or stretches the one there is, moving without it drops the
+   selection unless it was started with the mark key. */
+func selectFor(key easyterm.Key) {
+	if !isMovement(key.Code) {
+		return
+	}
+	if key.Mod&easyterm.ModShift != 0 {
+		if myState.anchor == nil {
+			setAnchor(false)
+		}
+	} else if !myState.markSet {
+		clearSelection()
+	}
+}
+
+/* The mark key starts a selection that plain moves stretch, pressed again it
+   drops it */
+func toggleMark() {
+	if myState.anchor != nil {
+		clearSelection()
+		setStatusMessage("Mark cleared")
+	} else {
+		setAnchor(true)
+		setStatusMessage("Mark set")
+	}
+	showEditorData()
+}
+
+func setAnchor(mark bool) {
+	pos := cursorEditPos()
+	myState.anchor = &pos
+	myState.markSet = mark
+}
+
+func clearSelection() {
+	myState.anchor = nil
+	myState.markSet = false
+}
+
+/* The text between the anchor and the cursor, false when there is none */
+func selectedRegion() (screenbuf.Region, bool) {
+	if myState.anchor == nil {
+		return screenbuf.Region{}, false
+	}
+	var (
+		start screenbuf.EditPos = *myState.anchor
+		end   screenbuf.EditPos = cursorEditPos()
+	)
+	if end.Before(start) {
+		start, end = end, start
+	}
+	return screenbuf.Region{Start: start, End: end}, start != end
+}
+
+/* Hands the selection over to the screen buffer, drawing the window again
+   when it changed */
+func updateSelection() {
+	var sel *screenbuf.Region
+	if r, ok := selectedRegion(); ok {
+		sel = &r
+	}
+	if (sel == nil) != (sb.Selection == nil) || (sel != nil && *sel != *sb.Selection) {
+		sb.Selection = sel
+		sb.ReprintBuffer()
+	}
+}
+
+/* Text between the ends of r, lines joined with \n */
+func regionText(r screenbuf.Region) string {
+	lines := sb.LineText(r.Start.Line, r.End.Line-r.Start.Line+1)
+	if len(lines) == 0 {
+		return ""
+	}
+	var last int = len(lines) - 1
+	lines[last] = lines[last][:sb.LineOffset(lines[last], r.End.Col)]
+	lines[0] = lines[0][sb.LineOffset(lines[0], r.Start.Col):]
+	return strings.Join(lines, "\n")
+}
+
+/* Puts text where r was as a single edit and leaves the cursor after it.
+   Every \n in text starts a new line. Returns where the text ended up. */
+func replaceRegion(r screenbuf.Region, text string) screenbuf.Region {
+	var count int = r.End.Line - r.Start.Line + 1
+	lines := sb.LineText(r.Start.Line, count)
+	if len(lines) != count {
+		return r
+	}
+	edit := beginEdit(screenbuf.EditReplace, r.Start.Line, count)
+	var (
+		head  string   = lines[0][:sb.LineOffset(lines[0], r.Start.Col)]
+		tail  string   = lines[count-1][sb.LineOffset(lines[count-1], r.End.Col):]
+		parts []string = strings.Split(text, "\n")
+		last  int      = len(parts) - 1
+	)
+	parts[0] = head + parts[0]
+	var end int = len(parts[last])
+	parts[last] += tail
+	sb.ReplaceLines(r.Start.Line, count, parts)
+
+	myState.currentLine = sb.GetLine(r.Start.Line + last)
+	myState.cursorPos.x = sb.RealColumn(parts[last], end)
+	sb.ReprintBuffer()
+	commitEdit(edit, len(parts))
+	showEditorData()
+	return screenbuf.Region{Start: r.Start, End: cursorEditPos()}
+}
+
+/* Deletes the selected text, returns false when nothing was selected */
+func deleteSelection() bool {
+	r, ok := selectedRegion()
+	if ok {
+		replaceRegion(r, "")
+	}
+	return ok
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var signals = make(chan os.Signal, 1)
+
+/* Signals waiting for the main goroutine, which owns the buffer and the
+   terminal */
+var caught = make(chan syscall.Signal, 8)
+
+var shutdownOnce sync.Once
+
+/* Starts listening for the signals winter cares about. Raw mode turns ISIG
+   off, so these come from kill or from the terminal going away rather than
+   from the keyboard. They are only passed on here, the main goroutine gets
+   woken up with a redraw and deals with them in handleCaughtSignals. */
+func handleSignals() {
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP,
+		syscall.SIGTSTP, syscall.SIGCONT)
+	go func() {
+		for sig := range signals {
+			select {
+			case caught <- sig.(syscall.Signal):
+			default:
+			}
+			myState.term.RequestRedraw()
+		}
+	}()
+}
+
+/* Handles the signals caught since the last call, the screen gets drawn
+   again afterwards */
+func handleCaughtSignals() {
+	for {
+		select {
+		case sig := <-caught:
+			switch sig {
+			case syscall.SIGTSTP:
+				suspend()
+			case syscall.SIGCONT:
+				// the redraw that follows is all it takes
+			default:
+				fatalSignal(sig)
+			}
+		default:
+			return
+		}
+	}
+}
+
+/* Gives the terminal back and stops the process like a regular Ctrl-Z would,
+   raw mode goes back on and everything gets drawn again once the shell lets
+   us run again */
+func suspend() {
+	myState.term.Clear()
+	myState.term.CursorPos(1, 1)
+	myState.term.End()
+	// the runtime keeps catching SIGTSTP once notified, SIGSTOP can't be
+	syscall.Kill(os.Getpid(), syscall.SIGSTOP)
+	myState.term.Init()
+	// the frame was cleared on the way out, the main loop draws it all again
+	myState.term.RequestRedraw()
+}
+
+/* Saves what can be saved and leaves the terminal as we found it */
+func fatalSignal(sig syscall.Signal) {
+	shutdown(sig.String(), 128+int(sig))
+}
+
+/* Writes the recovery file, gives the terminal back and exits with code.
+   Only the first call does it, a later one waits for the exit. */
+func shutdown(reason string, code int) {
+	shutdownOnce.Do(func() {
+		var recovered string = saveRecovery()
+		myState.term.Clear()
+		myState.term.CursorPos(1, 1)
+		myState.term.End()
+		if recovered != "" {
+			fmt.Fprintf(os.Stderr, "-winter: %v, unsaved changes written to %v\n", reason, recovered)
+		}
+		os.Exit(code)
+	})
+}
+
+/* Writes the recovery file if there is unsaved work, returns its path */
+func saveRecovery() string {
+	if sb == nil || !sb.Dirty {
+		return ""
+	}
+	path := sb.RecoveryPath()
+	if err := sb.WriteRecovery(path); err != nil {
+		return ""
+	}
+	return path
+}
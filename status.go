@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"screenbuf"
+	"strings"
+	"time"
+)
+
+/* How long a message stays on the message line */
+const MESSAGE_TIMEOUT = 5 * time.Second
+
+/* File types shown in the status bar, by extension */
+var fileTypes = map[string]string{
+	".go":   "Go",
+	".c":    "C",
+	".h":    "C",
+	".cpp":  "C++",
+	".hpp":  "C++",
+	".rs":   "Rust",
+	".py":   "Python",
+	".js":   "JavaScript",
+	".ts":   "TypeScript",
+	".java": "Java",
+	".rb":   "Ruby",
+	".sh":   "Shell",
+	".md":   "Markdown",
+	".json": "JSON",
+	".yml":  "YAML",
+	".yaml": "YAML",
+	".html": "HTML",
+	".css":  "CSS",
+	".txt":  "Text",
+}
+
+var (
+	statusMessage     string
+	statusMessageTime time.Time
+	showDebug         bool
+)
+
+/* Sets the message shown under the status bar for a few seconds */
+func setStatusMessage(format string, args ...interface{}) {
+	statusMessage = fmt.Sprintf(format, args...)
+	statusMessageTime = time.Now()
+}
+
+func fileType(name string) string {
+	if ft, ok := fileTypes[strings.ToLower(filepath.Ext(name))]; ok {
+		return ft
+	}
+	return "Text"
+}
+
+/* Redraws the status bar and the message line and puts the cursor back */
+func showEditorData() {
+	if myState.currentLine != nil {
+		updateSelection()
+		scrollToCursor()
+	}
+	drawStatusBar()
+	drawMessageLine()
+	if showDebug {
+		showDebugData()
+	}
+	if myState.currentLine != nil {
+		cursorToScreen()
+	} else {
+		myState.term.CursorPos(myState.cursorPos.y, myState.cursorPos.x)
+	}
+}
+
+func drawStatusBar() {
+	var (
+		name     string = sb.FileName
+		modified string
+		lines    string = fmt.Sprintf("%d", sb.Size())
+		line     int
+	)
+	if name == "" {
+		name = "[No Name]"
+	}
+	if sb.Dirty {
+		modified = " (modified)"
+	}
+	if !sb.AllLoaded() {
+		lines += "+"
+	}
+	if myState.currentLine != nil {
+		line = myState.currentLine.Index()
+	}
+	left := fmt.Sprintf(" %s%s - %s lines", name, modified, lines)
+	position := fmt.Sprintf("Ln %d, Col %d ", line, myState.cursorPos.x)
+	right := fmt.Sprintf("%s | %s | ", fileType(name), sb.LineEnding) + position
+
+	// on a narrow terminal the file type goes first, then the left side
+	// gets cut, where the cursor is always shows
+	if sb.Width(left)+sb.Width(right) >= sb.DefaultWidth {
+		right = position
+	}
+	left = fitWidth(left, sb.DefaultWidth-sb.Width(right)-1)
+	bar := left
+	if gap := sb.DefaultWidth - sb.Width(left) - sb.Width(right); gap > 0 {
+		bar += strings.Repeat(" ", gap)
+	}
+	bar = fitWidth(bar+right, sb.DefaultWidth)
+
+	myState.term.CursorPos(sb.DefaultHeight-1, 1)
+	myState.term.ClearLine()
+	myState.term.InvertColors(true)
+	fmt.Fprint(myState.term, bar)
+	myState.term.InvertColors(false)
+}
+
+func drawMessageLine() {
+	myState.term.CursorPos(sb.DefaultHeight, 1)
+	myState.term.ClearLine()
+	if statusMessage != "" && time.Since(statusMessageTime) < MESSAGE_TIMEOUT {
+		fmt.Fprint(myState.term, fitWidth(statusMessage, sb.DefaultWidth))
+	}
+}
+
+/* Cuts text down to the whole characters that fit in width columns */
+func fitWidth(text string, width int) string {
+	var col int = 0
+	for i, r := range text {
+		if col += screenbuf.RuneWidth(r); col > width {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+/* The old debug overlay, drawn on the top right corner when toggled on */
+func showDebugData() {
+	var col int = sb.DefaultWidth - 40
+	if col < 1 {
+		col = 1
+	}
+	data := []string{
+		"Buffer Length: " + fmt.Sprint(sb.Size()),
+		fmt.Sprintf("X: %v Y: %v", myState.cursorPos.x, myState.cursorPos.y),
+		"DEFAULT_HEIGHT: " + fmt.Sprint(sb.DefaultHeight),
+		fmt.Sprintf("Index of first visible line: %v", sb.IndexOfFirstVisibleLine),
+		fmt.Sprintf("Index of last visible line: %v", sb.IndexOfLastVisisbleLine),
+	}
+	if myState.currentLine != nil {
+		data = append([]string{fmt.Sprintf("Current Line Index: %v", myState.currentLine.Index())}, data...)
+	}
+	for i, text := range data {
+		myState.term.CursorPos(i+1, col)
+		myState.term.ClearFromCursor()
+		fmt.Fprint(myState.term, text)
+	}
+}
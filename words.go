@@ -0,0 +1,106 @@
+package main
+
+import (
+	"screenbuf"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/* Characters that end a word besides white space, change this to make words
+   bigger or smaller */
+var wordSeparators string = "`~!@#$%^&*()-=+[{]}\\|;:'\",.<>/?"
+
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(wordSeparators, r)
+}
+
+/* Byte offset in line where the word at or after offset ends. Works on the
+   text with its tabs, so the cursor never ends up inside the columns a tab
+   takes on screen. */
+func nextWordEnd(line string, offset int) int {
+	for offset < len(line) && !wordAt(line, offset) {
+		offset = screenbuf.NextOffset(line, offset)
+	}
+	for offset < len(line) && wordAt(line, offset) {
+		offset = screenbuf.NextOffset(line, offset)
+	}
+	return offset
+}
+
+/* Byte offset in line where the word before offset starts */
+func prevWordStart(line string, offset int) int {
+	for offset > 0 && !wordAt(line, screenbuf.PrevOffset(line, offset)) {
+		offset = screenbuf.PrevOffset(line, offset)
+	}
+	for offset > 0 && wordAt(line, screenbuf.PrevOffset(line, offset)) {
+		offset = screenbuf.PrevOffset(line, offset)
+	}
+	return offset
+}
+
+func wordAt(line string, offset int) bool {
+	r, _ := utf8.DecodeRuneInString(line[offset:])
+	return isWordRune(r)
+}
+
+/* Ctrl-Left and Ctrl-Right, past the ends of a line it goes on to the next
+   or previous one */
+func moveWord(forward bool) {
+	var (
+		line   string = myState.currentLine.Line
+		offset int    = sb.LineOffset(line, myState.cursorPos.x)
+	)
+	switch {
+	case forward && offset < len(line):
+		offset = nextWordEnd(line, offset)
+	case !forward && offset > 0:
+		offset = prevWordStart(line, offset)
+	case forward:
+		if next := sb.NextLine(myState.currentLine); next != nil {
+			myState.currentLine = next
+			myState.cursorPos.x = 1
+		}
+		showEditorData()
+		return
+	default:
+		if prev := sb.PrevLine(myState.currentLine); prev != nil {
+			myState.currentLine = prev
+			myState.cursorPos.x = prev.Length + 1
+		}
+		showEditorData()
+		return
+	}
+	myState.cursorPos.x = sb.RealColumn(line, offset)
+	showEditorData()
+}
+
+/* Deletes up to the start of the previous word or the end of the next one.
+   At the edge of a line it joins lines like Backspace and Delete do. */
+func deleteWord(forward bool) {
+	var (
+		line     string = myState.currentLine.Line
+		offset   int    = sb.LineOffset(line, myState.cursorPos.x)
+		from, to int    = offset, offset
+	)
+	if forward {
+		to = nextWordEnd(line, offset)
+	} else {
+		from = prevWordStart(line, offset)
+	}
+	if from == to {
+		if forward {
+			deleteLetter()
+		} else {
+			backspaceLetter()
+		}
+		return
+	}
+	edit := beginEdit(screenbuf.EditDelete, myState.currentLine.Index(), 1)
+	line = line[:from] + line[to:]
+	sb.SetLine(myState.currentLine, line)
+	sb.RedrawLine(myState.currentLine)
+	myState.cursorPos.x = sb.RealColumn(line, from)
+	commitEdit(edit, 1)
+	showEditorData()
+}